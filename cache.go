@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// cacheEntry records what we saw for one object the last time we rendered
+// it, so a later run can tell whether it actually changed.
+type cacheEntry struct {
+	ResourceVersion string `json:"resourceVersion"`
+	Hash            string `json:"hash"`
+}
+
+// Cache is a small on-disk cache, keyed by {context, GVR, namespace, name},
+// used to skip re-writing resource files that haven't changed between runs.
+type Cache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cachePath returns $XDG_CACHE_HOME/fogcrow/<context>.db (falling back to
+// ~/.cache when XDG_CACHE_HOME is unset), mirroring the location kubectl and
+// similar tools use for their own caches.
+func cachePath(kubeContext string) (string, error) {
+	base := os.Getenv("XDG_CACHE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error resolving cache directory: %w", err)
+		}
+		base = filepath.Join(home, ".cache")
+	}
+
+	name := kubeContext
+	if name == "" {
+		name = "default"
+	}
+
+	return filepath.Join(base, "fogcrow", name+".db"), nil
+}
+
+// loadCache reads the cache file at path if it exists, or returns an empty
+// cache otherwise.
+func loadCache(path string) (*Cache, error) {
+	cache := &Cache{path: path, entries: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &cache.entries); err != nil {
+		return nil, fmt.Errorf("error parsing cache %s: %w", path, err)
+	}
+
+	return cache, nil
+}
+
+func cacheKey(kubeContext string, resource Resource, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s/%s", kubeContext, resource.Group, resource.Version, resource.Name, namespace, name)
+}
+
+func hashObject(obj *unstructured.Unstructured) string {
+	encoded, err := json.Marshal(obj.Object)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) lookup(key string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	return entry, ok
+}
+
+func (c *Cache) update(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = entry
+}
+
+// save writes the cache back to disk, creating its parent directory if
+// necessary.
+func (c *Cache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(c.path), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("error encoding cache: %w", err)
+	}
+
+	if err := os.WriteFile(c.path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing cache %s: %w", c.path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// requestTimeout bounds every discovery and list call so a slow or
+// unhealthy API server can't hang a worker slot indefinitely -- only an
+// external SIGINT rescued that before.
+const requestTimeout = 30 * time.Second
+
+// newClients builds the discovery and dynamic clients used to enumerate and
+// list resources, replacing the previous "exec kubectl" pipeline.
+func newClients(kubeContext string) (discovery.DiscoveryInterface, dynamic.Interface, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{}
+	if kubeContext != "" {
+		overrides.CurrentContext = kubeContext
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error building client config: %w", err)
+	}
+	restConfig.Timeout = requestTimeout
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error building discovery client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error building dynamic client: %w", err)
+	}
+
+	return discoveryClient, dynamicClient, nil
+}
+
+// listResources enumerates the API resources the server knows about,
+// replacing the line-oriented parsing of `kubectl api-resources` output.
+func listResources(discoveryClient discovery.DiscoveryInterface) ([]Resource, error) {
+	_, apiResourceLists, err := discoveryClient.ServerGroupsAndResources()
+	if err != nil && apiResourceLists == nil {
+		return nil, fmt.Errorf("error listing server resources: %w", err)
+	}
+
+	var resources []Resource
+	for _, list := range apiResourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+
+		for _, apiResource := range list.APIResources {
+			if apiResource.Name == "" {
+				continue
+			}
+
+			resources = append(resources, Resource{
+				Name:       apiResource.Name,
+				ShortNames: joinShortNames(apiResource.ShortNames),
+				APIVersion: list.GroupVersion,
+				Namespaced: apiResource.Namespaced,
+				Kind:       apiResource.Kind,
+				Group:      gv.Group,
+				Version:    gv.Version,
+			})
+		}
+	}
+
+	return resources, nil
+}
+
+func joinShortNames(shortNames []string) string {
+	if len(shortNames) == 0 {
+		return ""
+	}
+
+	joined := shortNames[0]
+	for _, name := range shortNames[1:] {
+		joined += "," + name
+	}
+
+	return joined
+}
+
+// listObjects lists every object of the given resource across all
+// namespaces (or cluster-wide, for cluster-scoped resources).
+func listObjects(ctx context.Context, dynamicClient dynamic.Interface, resource Resource) (*unstructured.UnstructuredList, error) {
+	gvr := schema.GroupVersionResource{
+		Group:    resource.Group,
+		Version:  resource.Version,
+		Resource: resource.Name,
+	}
+
+	var resourceInterface dynamic.ResourceInterface
+	if resource.Namespaced {
+		resourceInterface = dynamicClient.Resource(gvr).Namespace(metav1.NamespaceAll)
+	} else {
+		resourceInterface = dynamicClient.Resource(gvr)
+	}
+
+	return resourceInterface.List(ctx, metav1.ListOptions{})
+}
@@ -0,0 +1,32 @@
+package main
+
+import "strings"
+
+// MultiError aggregates the independent per-resource failures collected
+// while processing resources concurrently, so callers see every failure
+// instead of only the first one.
+type MultiError struct {
+	Errors []error
+}
+
+func (m *MultiError) Error() string {
+	messages := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
+
+// NewMultiError returns a *MultiError wrapping errs, or nil if errs is empty.
+func NewMultiError(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return &MultiError{Errors: errs}
+}
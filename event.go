@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Event is one entry in the structured progress/error stream emitted while
+// processing resources. Type is one of "start", "resource_done",
+// "resource_error", or "summary".
+type Event struct {
+	Type     string        `json:"type"`
+	Resource string        `json:"resource,omitempty"`
+	Bytes    int64         `json:"bytes,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Err      string        `json:"err,omitempty"`
+	Message  string        `json:"message,omitempty"`
+}
+
+// Emitter receives Events as processing progresses. Implementations must be
+// safe for concurrent use, since resources are processed in parallel.
+type Emitter interface {
+	Emit(Event)
+}
+
+// newEmitter picks a JSON emitter writing to eventsFile when one is given,
+// otherwise a human-readable renderer for TTYs or a JSON stream for
+// anything else (so CI/automation can consume it without a flag).
+func newEmitter(eventsFile string) (Emitter, func(), error) {
+	if eventsFile != "" {
+		file, err := os.Create(eventsFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating events file %s: %w", eventsFile, err)
+		}
+		return newJSONEmitter(file), func() { file.Close() }, nil
+	}
+
+	if isTerminal(os.Stderr) {
+		return newHumanEmitter(os.Stderr), func() {}, nil
+	}
+
+	return newJSONEmitter(os.Stderr), func() {}, nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// noopEmitter discards every event; useful where no destination is wired
+// up, such as tests exercising exploreProcessResources directly.
+type noopEmitter struct{}
+
+func (noopEmitter) Emit(Event) {}
+
+// jsonEmitter writes one JSON-encoded Event per line.
+type jsonEmitter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func newJSONEmitter(w io.Writer) *jsonEmitter {
+	return &jsonEmitter{w: w}
+}
+
+func (j *jsonEmitter) Emit(event Event) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	encoder := json.NewEncoder(j.w)
+	if err := encoder.Encode(event); err != nil {
+		fmt.Fprintf(os.Stderr, "error encoding event: %v\n", err)
+	}
+}
+
+// humanEmitter renders events as short, readable lines for an interactive
+// terminal.
+type humanEmitter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func newHumanEmitter(w io.Writer) *humanEmitter {
+	return &humanEmitter{w: w}
+}
+
+func (h *humanEmitter) Emit(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch event.Type {
+	case "start":
+		fmt.Fprintf(h.w, "start  %s\n", event.Resource)
+	case "resource_done":
+		fmt.Fprintf(h.w, "done   %s (%d bytes, %s)\n", event.Resource, event.Bytes, event.Duration)
+	case "resource_error":
+		fmt.Fprintf(h.w, "error  %s: %s\n", event.Resource, event.Err)
+	case "summary":
+		fmt.Fprintf(h.w, "summary: %s\n", event.Message)
+	default:
+		fmt.Fprintf(h.w, "%s  %s\n", event.Type, event.Resource)
+	}
+}
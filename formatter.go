@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Formatter renders a resource's listed objects to a writer and knows how to
+// merge several already-rendered files together for concatenateLogs.
+type Formatter interface {
+	// Extension returns the file extension (without a leading dot) used for
+	// per-resource output files, e.g. "json", "yaml", "ndjson".
+	Extension() string
+
+	// Write renders list to w in this formatter's encoding.
+	Write(w io.Writer, list *unstructured.UnstructuredList) error
+
+	// Merge reads the per-resource files at paths (in order) and writes a
+	// single combined document to w.
+	Merge(w io.Writer, paths []string) error
+}
+
+func newFormatter(name string) (Formatter, error) {
+	switch name {
+	case "json":
+		return jsonFormatter{}, nil
+	case "yaml":
+		return yamlFormatter{}, nil
+	case "ndjson":
+		return ndjsonFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (want json, yaml, or ndjson)", name)
+	}
+}
+
+// ndjsonFormatter writes one JSON object per line, matching the original
+// per-resource output, and merges files by plain concatenation.
+type ndjsonFormatter struct{}
+
+func (ndjsonFormatter) Extension() string { return "ndjson" }
+
+func (ndjsonFormatter) Write(w io.Writer, list *unstructured.UnstructuredList) error {
+	encoder := json.NewEncoder(w)
+	for _, item := range list.Items {
+		if err := encoder.Encode(item.Object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ndjsonFormatter) Merge(w io.Writer, paths []string) error {
+	return concatenateFiles(w, paths, "")
+}
+
+// jsonFormatter writes a pretty-printed JSON array per resource, and merges
+// per-resource arrays into a single top-level JSON array.
+type jsonFormatter struct{}
+
+func (jsonFormatter) Extension() string { return "json" }
+
+func (jsonFormatter) Write(w io.Writer, list *unstructured.UnstructuredList) error {
+	objects := make([]map[string]interface{}, 0, len(list.Items))
+	for _, item := range list.Items {
+		objects = append(objects, item.Object)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(objects)
+}
+
+func (jsonFormatter) Merge(w io.Writer, paths []string) error {
+	merged := make([]json.RawMessage, 0, len(paths))
+	for _, path := range paths {
+		var objects []json.RawMessage
+		if err := readJSONFile(path, &objects); err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		merged = append(merged, objects...)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(merged)
+}
+
+// yamlFormatter writes each object as its own `---`-separated YAML document.
+type yamlFormatter struct{}
+
+func (yamlFormatter) Extension() string { return "yaml" }
+
+func (yamlFormatter) Write(w io.Writer, list *unstructured.UnstructuredList) error {
+	for _, item := range list.Items {
+		if _, err := fmt.Fprintln(w, "---"); err != nil {
+			return err
+		}
+
+		encoded, err := yaml.Marshal(item.Object)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(encoded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (yamlFormatter) Merge(w io.Writer, paths []string) error {
+	return concatenateFiles(w, paths, "")
+}
+
+func concatenateFiles(w io.Writer, paths []string, separator string) error {
+	for i, path := range paths {
+		if i > 0 && separator != "" {
+			if _, err := io.WriteString(w, separator); err != nil {
+				return err
+			}
+		}
+
+		if err := copyFile(w, path); err != nil {
+			return fmt.Errorf("error copying %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func copyFile(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, bufio.NewReader(file))
+	return err
+}
+
+func readJSONFile(path string, v interface{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return json.NewDecoder(file).Decode(v)
+}
@@ -1,15 +1,18 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
-	"strings"
+	"sort"
 	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/client-go/dynamic"
 )
 
 type Resource struct {
@@ -18,255 +21,247 @@ type Resource struct {
 	APIVersion string `json:"apiVersion"`
 	Namespaced bool   `json:"namespaced"`
 	Kind       string `json:"kind"`
+	Group      string `json:"group"`
+	Version    string `json:"version"`
 }
 
 type CommandOutput struct {
 	ResourceName string
-	CommandLog   string
-	Stdout       string
 	Stderr       string
 }
 
 func main() {
-	var context string
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		if err := runServe(args[1:]); err != nil {
+			fmt.Printf("Error running server: %v\n", err)
+		}
+		return
+	}
+
+	runDump(args)
+}
 
-	outputDir := flag.String("output", "resources", "Output directory for logs")
-	maxChannels := flag.Int("max-channels", 2, "Maximum number of concurrent goroutines")
-	flag.StringVar(&context, "context", "", "Use kubectl context")
-	flag.Parse()
-	command := []string{"kubectl", "api-resources"}
+// runDump is the original CLI behavior: discover resources, dump each to a
+// file under -output, then concatenate them into a single log file.
+func runDump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+
+	var kubeContext string
+	outputDir := fs.String("output", "resources", "Output directory for logs")
+	maxChannels := fs.Int("max-channels", 2, "Maximum number of concurrent goroutines")
+	format := fs.String("format", "ndjson", "Output format for per-resource files: json, yaml, or ndjson")
+	noCache := fs.Bool("no-cache", false, "Disable the resourceVersion cache and always rewrite every resource file")
+	failOnChange := fs.Bool("fail-on-change", false, "Exit non-zero if any resource changed since the last run (for CI drift detection)")
+	eventsFile := fs.String("events-file", "", "Write the JSON event stream to this file instead of stderr")
+	fs.StringVar(&kubeContext, "context", "", "Kubernetes context to use")
+	fs.Parse(args)
+
+	emitter, closeEmitter, err := newEmitter(*eventsFile)
+	if err != nil {
+		fmt.Printf("Error setting up event emitter: %v\n", err)
+		return
+	}
+	defer closeEmitter()
 
-	if context == "" {
+	if kubeContext == "" {
 		fmt.Println("Using default context")
-	} else {
-		command = append(command, "--context", context)
 	}
 
-	cmd := exec.Command(command[0], command[1:]...)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	stdout, err := cmd.StdoutPipe()
+	formatter, err := newFormatter(*format)
 	if err != nil {
-		fmt.Printf("Error creating stdout pipe: %v", err)
+		fmt.Printf("Error: %v\n", err)
 		return
 	}
 
-	err = cmd.Start()
+	discoveryClient, dynamicClient, err := newClients(kubeContext)
 	if err != nil {
-		fmt.Printf("Error starting command: %v", err)
+		fmt.Printf("Error building Kubernetes clients: %v\n", err)
 		return
 	}
 
-	resourceList := make([]Resource, 0)
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) == 4 {
-			resource := Resource{
-				Name:       fields[0],
-				APIVersion: fields[1],
-				Namespaced: fields[2] == "true",
-				Kind:       fields[3],
-			}
-			resourceList = append(resourceList, resource)
+	resourceList, err := listResources(discoveryClient)
+	if err != nil {
+		fmt.Printf("Error listing API resources: %v\n", err)
+		return
+	}
+
+	writer := newFileResourceWriter(*outputDir, formatter)
+
+	var cache *Cache
+	if !*noCache {
+		path, err := cachePath(kubeContext)
+		if err != nil {
+			fmt.Printf("Error resolving cache path: %v\n", err)
+			return
 		}
-		if len(fields) >= 5 {
-			resource := Resource{
-				Name:       fields[0],
-				ShortNames: fields[1],
-				APIVersion: fields[2],
-				Namespaced: fields[3] == "true",
-				Kind:       fields[4],
-			}
-			resourceList = append(resourceList, resource)
+
+		cache, err = loadCache(path)
+		if err != nil {
+			fmt.Printf("Error loading cache: %v\n", err)
+			return
 		}
-	}
 
-	err = cmd.Wait()
-	if err != nil {
-		fmt.Printf("Error waiting for command: %v", err)
-		return
+		writer.kubeContext = kubeContext
+		writer.cache = cache
 	}
 
-	err = exploreProcessResources(resourceList, *outputDir, *maxChannels, context)
+	err = exploreProcessResources(ctx, dynamicClient, resourceList, *maxChannels, writer, emitter)
 	if err != nil {
 		fmt.Printf("Error processing resources: %v\n", err)
 		return
 	}
 
-	err = concatenateLogs(*outputDir)
+	err = concatenateLogs(*outputDir, formatter, emitter)
 	if err != nil {
 		fmt.Printf("Error concatenating logs: %v\n", err)
 		return
 	}
-}
 
-func exploreProcessResources(resources []Resource, outputDir string, maxChannels int, context string) error {
-	fmt.Printf("Parsed resources:\n")
+	if cache != nil {
+		fmt.Println(writer.stats.String())
 
-	// Create a channel to control the number of concurrent goroutines
-	concurrency := make(chan struct{}, maxChannels)
+		if err := cache.save(); err != nil {
+			fmt.Printf("Error saving cache: %v\n", err)
+			return
+		}
 
-	// Create a channel to collect errors from goroutines
-	errCh := make(chan error)
+		if *failOnChange && writer.stats.unchanged != writer.stats.total {
+			fmt.Println("Resources changed since the last run")
+			os.Exit(1)
+		}
+	}
+}
 
-	// Create a wait group to wait for all goroutines to finish
-	var wg sync.WaitGroup
+// exploreProcessResources fans out one goroutine per resource, capped at
+// maxChannels concurrent in flight via errgroup.SetLimit, and returns a
+// MultiError aggregating every per-resource failure instead of only the
+// first one. Canceling ctx (e.g. on SIGINT, or an HTTP client disconnecting
+// in `serve` mode) stops in-flight listings and short-circuits any
+// resources not yet started.
+func exploreProcessResources(ctx context.Context, dynamicClient dynamic.Interface, resources []Resource, maxChannels int, writer ResourceWriter, emitter Emitter) error {
+	fmt.Printf("Parsed resources:\n")
 
-	for _, resource := range resources {
-		wg.Add(1)
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(maxChannels)
 
-		// Launch a goroutine to process each resource concurrently
-		go func(resource Resource) {
-			defer wg.Done()
+	var mu sync.Mutex
+	var errs []error
 
-			concurrency <- struct{}{} // Acquire a slot in the concurrency channel
-			defer func() {
-				<-concurrency // Release the slot in the concurrency channel
-			}()
+	for _, resource := range resources {
+		resource := resource
 
-			output := processResource(resource, outputDir, context)
+		group.Go(func() error {
+			output := processResource(ctx, dynamicClient, resource, writer, emitter)
 			if output.Stderr != "" {
-				errCh <- fmt.Errorf("error processing resource %s: %v", resource.Name, output.Stderr)
+				err := fmt.Errorf("error processing resource %s: %v", resource.Name, output.Stderr)
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				fmt.Println(err)
 			}
-		}(resource)
+			return nil
+		})
 	}
 
-	// Start a goroutine to wait for all goroutines to finish and close the error channel
-	go func() {
-		wg.Wait()
-		close(errCh)
-	}()
-
-	// Collect errors from the error channel
-	for err := range errCh {
-		fmt.Println(err)
+	if err := group.Wait(); err != nil {
+		return err
 	}
 
-	return nil
-}
+	err := NewMultiError(errs)
 
-func processResource(resource Resource, outputDir string, context string) CommandOutput {
-	command := "kubectl"
-	cmdArgs := []string{"get", "--all-namespaces", resource.Name}
-	if context != "" {
-		cmdArgs = append(cmdArgs, "--context", context)
-	}
-	joined := strings.Join(cmdArgs, " ")
-	commandLog := fmt.Sprintf("Running command: %s %s\n", command, joined)
+	emitter.Emit(Event{
+		Type:    "summary",
+		Message: fmt.Sprintf("%d resources processed, %d errors", len(resources), len(errs)),
+	})
+
+	return err
+}
 
+func processResource(ctx context.Context, dynamicClient dynamic.Interface, resource Resource, writer ResourceWriter, emitter Emitter) CommandOutput {
 	output := CommandOutput{
 		ResourceName: resource.Name,
-		CommandLog:   commandLog,
 	}
 
-	err := os.MkdirAll(outputDir, os.ModePerm)
-	if err != nil {
-		output.Stderr = fmt.Sprintf("error creating output directory: %v", err)
-		return output
-	}
+	emitter.Emit(Event{Type: "start", Resource: resource.Name})
+	start := time.Now()
 
-	filePath := filepath.Join(outputDir, fmt.Sprintf("%s.log", resource.Name))
-	file, err := os.Create(filePath)
-	if err != nil {
-		output.Stderr = fmt.Sprintf("error creating file %s: %v", filePath, err)
+	if err := ctx.Err(); err != nil {
+		output.Stderr = fmt.Sprintf("context canceled before processing resource: %v", err)
+		emitter.Emit(Event{Type: "resource_error", Resource: resource.Name, Err: output.Stderr, Duration: time.Since(start)})
 		return output
 	}
-	defer file.Close()
-
-	writer := bufio.NewWriter(file)
-	defer writer.Flush()
 
-	_, err = writer.WriteString(commandLog)
+	list, err := listObjects(ctx, dynamicClient, resource)
 	if err != nil {
-		output.Stderr = fmt.Sprintf("error writing to file %s: %v", filePath, err)
+		output.Stderr = fmt.Sprintf("error listing resource %s: %v", resource.Name, err)
+		emitter.Emit(Event{Type: "resource_error", Resource: resource.Name, Err: output.Stderr, Duration: time.Since(start)})
 		return output
 	}
 
-	getCmd := exec.Command(command, cmdArgs...)
-	stdoutPipe, err := getCmd.StdoutPipe()
+	description, bytesWritten, err := writer.Write(resource, list)
 	if err != nil {
-		output.Stderr = fmt.Sprintf("error creating stdout pipe: %v", err)
-		return output
-	}
-	stderrPipe, err := getCmd.StderrPipe()
-	if err != nil {
-		output.Stderr = fmt.Sprintf("error creating stderr pipe: %v", err)
+		output.Stderr = err.Error()
+		emitter.Emit(Event{Type: "resource_error", Resource: resource.Name, Err: output.Stderr, Duration: time.Since(start)})
 		return output
 	}
 
-	err = getCmd.Start()
-	if err != nil {
-		output.Stderr = fmt.Sprintf("error starting command: %v", err)
-		return output
-	}
+	emitter.Emit(Event{Type: "resource_done", Resource: resource.Name, Bytes: bytesWritten, Duration: time.Since(start)})
+	fmt.Printf("Writing %s\n", description)
 
-	_, err = io.Copy(writer, stdoutPipe)
-	if err != nil {
-		output.Stderr = fmt.Sprintf("error copying stdout to file: %v", err)
-		return output
-	}
+	return output
+}
 
-	_, err = io.Copy(writer, stderrPipe)
-	if err != nil {
-		output.Stderr = fmt.Sprintf("error copying stderr to file: %v", err)
-		return output
-	}
+// concatenateLogs merges every per-resource file in outputDir into a single
+// log.<ext> file, using the formatter that produced them so the merged
+// result stays valid (e.g. one JSON array rather than concatenated bytes).
+func concatenateLogs(outputDir string, formatter Formatter, emitter Emitter) error {
+	logFilePath := filepath.Join(outputDir, fmt.Sprintf("log.%s", formatter.Extension()))
 
-	err = getCmd.Wait()
+	paths, err := resourceFilePaths(outputDir, logFilePath)
 	if err != nil {
-		output.Stderr = fmt.Sprintf("error running kubectl get command for resource %s: %v", resource.Name, err)
-		return output
+		return fmt.Errorf("error listing resource files in %s: %v", outputDir, err)
 	}
 
-	fmt.Printf("Writing %s\n", filePath)
-
-	return output
-}
-
-func concatenateLogs(outputDir string) error {
-	logFilePath := filepath.Join(outputDir, "log.txt")
 	logFile, err := os.Create(logFilePath)
 	if err != nil {
 		return fmt.Errorf("error creating log file %s: %v", logFilePath, err)
 	}
 	defer logFile.Close()
 
-	writer := bufio.NewWriter(logFile)
-	defer writer.Flush()
+	if err := formatter.Merge(logFile, paths); err != nil {
+		return fmt.Errorf("error merging resource files: %v", err)
+	}
+
+	emitter.Emit(Event{
+		Type:    "summary",
+		Message: fmt.Sprintf("concatenated %d resource files into %s", len(paths), logFilePath),
+	})
+	fmt.Printf("Logs concatenated to file %s\n", logFilePath)
+
+	return nil
+}
 
-	err = filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+func resourceFilePaths(outputDir string, logFilePath string) ([]string, error) {
+	var paths []string
+
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() {
-			if path != logFilePath {
-				file, err := os.Open(path)
-				if err != nil {
-					return fmt.Errorf("error opening file %s: %v", path, err)
-				}
-				defer file.Close()
-
-				_, err = io.Copy(writer, file)
-				if err != nil {
-					return fmt.Errorf("error copying file contents to log file: %v", err)
-				}
-
-				_, err = writer.Write([]byte("\n\n"))
-				if err != nil {
-					return fmt.Errorf("error appending newline: %v", err)
-				}
-
-			}
+		if !info.IsDir() && path != logFilePath {
+			paths = append(paths, path)
 		}
 		return nil
 	})
-
 	if err != nil {
-		return fmt.Errorf("error walking through directory %s: %v", outputDir, err)
+		return nil, err
 	}
 
-	fmt.Printf("Logs concatenated to file %s\n", logFilePath)
+	sort.Strings(paths)
 
-	return nil
+	return paths, nil
 }
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestExploreProcessResourcesPartialFailure(t *testing.T) {
+	scheme := runtime.NewScheme()
+	widgetsGVR := schema.GroupVersionResource{Group: "nope.example.com", Version: "v1", Resource: "widgets"}
+
+	// Every GVR the fake client will List needs a registered list kind, or
+	// List panics instead of returning an error (see
+	// NewSimpleDynamicClientWithCustomListKinds). widgets is registered too,
+	// but a reactor below makes its List call fail the way a real API
+	// server error would, so this exercises partial failure instead of a
+	// coding-error panic.
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(scheme, map[schema.GroupVersionResource]string{
+		{Group: "", Version: "v1", Resource: "pods"}: "PodList",
+		widgetsGVR: "WidgetList",
+	})
+	dynamicClient.PrependReactor("list", "widgets", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("simulated server error listing widgets")
+	})
+
+	resources := []Resource{
+		{Name: "pods", Namespaced: true, Group: "", Version: "v1"},
+		{Name: "widgets", Namespaced: true, Group: "nope.example.com", Version: "v1"},
+	}
+
+	outputDir := t.TempDir()
+	formatter, err := newFormatter("ndjson")
+	if err != nil {
+		t.Fatalf("newFormatter: %v", err)
+	}
+	writer := newFileResourceWriter(outputDir, formatter)
+
+	err = exploreProcessResources(context.Background(), dynamicClient, resources, 2, writer, noopEmitter{})
+	if err == nil {
+		t.Fatalf("expected an error for the unknown resource, got nil")
+	}
+	if !strings.Contains(err.Error(), "widgets") {
+		t.Fatalf("expected error to mention widgets, got: %v", err)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(outputDir, "pods.ndjson")); statErr != nil {
+		t.Fatalf("expected pods.ndjson to be written despite the other failure: %v", statErr)
+	}
+	if _, statErr := os.Stat(filepath.Join(outputDir, "widgets.ndjson")); statErr == nil {
+		t.Fatalf("expected widgets.ndjson to not be written")
+	}
+}
+
+func TestExploreProcessResourcesCancellation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	dynamicClient := fake.NewSimpleDynamicClient(scheme)
+
+	resources := []Resource{
+		{Name: "pods", Namespaced: true, Group: "", Version: "v1"},
+	}
+
+	outputDir := t.TempDir()
+	formatter, err := newFormatter("ndjson")
+	if err != nil {
+		t.Fatalf("newFormatter: %v", err)
+	}
+	writer := newFileResourceWriter(outputDir, formatter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = exploreProcessResources(ctx, dynamicClient, resources, 2, writer, noopEmitter{})
+	if err == nil {
+		t.Fatalf("expected an error once the context is already canceled, got nil")
+	}
+	if !strings.Contains(err.Error(), "canceled") {
+		t.Fatalf("expected error to mention cancellation, got: %v", err)
+	}
+
+	if _, statErr := os.Stat(filepath.Join(outputDir, "pods.ndjson")); statErr == nil {
+		t.Fatalf("expected pods.ndjson to not be written after cancellation")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+)
+
+// runServe starts the HTTP API described by the -addr flag, exposing the
+// same discovery+dump functionality as `dump` mode over the network instead
+// of writing to local files.
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+
+	var defaultContext string
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	maxChannels := fs.Int("max-channels", 2, "Maximum number of concurrent goroutines per request")
+	eventsFile := fs.String("events-file", "", "Write the JSON event stream to this file instead of stderr")
+	fs.StringVar(&defaultContext, "context", "", "Default Kubernetes context, used when a request omits ?context=")
+	fs.Parse(args)
+
+	emitter, closeEmitter, err := newEmitter(*eventsFile)
+	if err != nil {
+		return fmt.Errorf("error setting up event emitter: %w", err)
+	}
+	defer closeEmitter()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/resources", handleResources(defaultContext))
+	mux.HandleFunc("/dump", handleDump(defaultContext, *maxChannels, emitter))
+
+	fmt.Printf("Listening on %s\n", *addr)
+	return http.ListenAndServe(*addr, mux)
+}
+
+func requestContext(r *http.Request, defaultContext string) string {
+	if c := r.URL.Query().Get("context"); c != "" {
+		return c
+	}
+	return defaultContext
+}
+
+// handleResources serves GET /resources?context=..., listing the API
+// resources the cluster knows about as a JSON array.
+func handleResources(defaultContext string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		discoveryClient, _, err := newClients(requestContext(r, defaultContext))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error building Kubernetes clients: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		resourceList, err := listResources(discoveryClient)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error listing resources: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resourceList); err != nil {
+			http.Error(w, fmt.Sprintf("error encoding resources: %v", err), http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleDump serves GET /dump?context=...&format=ndjson, streaming each
+// resource's rendered objects as soon as it's listed so a huge cluster
+// never has to buffer in memory. It reuses the same errgroup-backed worker
+// pool as `dump` mode, writing each resource's chunk straight to the
+// response instead of to a file, and flushing after every chunk so the
+// response goes out as Transfer-Encoding: chunked. Canceling the request
+// context (the client disconnecting) stops in-flight listings. format=json
+// is rejected: each resource would stream its own complete top-level JSON
+// array, leaving the body as several concatenated arrays rather than one
+// document, so only the self-delimiting ndjson and yaml formats are valid
+// here. Per-resource failures go out on the emitter's event stream as they
+// happen; a failure in the overall run is reported via the X-Dump-Error
+// trailer rather than written into the body, so a partial failure never
+// corrupts an otherwise-valid ndjson/yaml document.
+func handleDump(defaultContext string, maxChannels int, emitter Emitter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "ndjson"
+		}
+		if format == "json" {
+			http.Error(w, "format=json is not supported by /dump: concurrently streamed resources would each write their own top-level JSON array, producing several arrays concatenated together instead of one parseable document; use ndjson or yaml", http.StatusBadRequest)
+			return
+		}
+
+		formatter, err := newFormatter(format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		discoveryClient, dynamicClient, err := newClients(requestContext(r, defaultContext))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error building Kubernetes clients: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		resourceList, err := listResources(discoveryClient)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("error listing resources: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/"+formatter.Extension())
+		w.Header().Set("Trailer", "X-Dump-Error")
+		flusher, _ := w.(http.Flusher)
+
+		writer := newStreamResourceWriter(w, formatter, func() {
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+
+		if err := exploreProcessResources(r.Context(), dynamicClient, resourceList, maxChannels, writer, emitter); err != nil {
+			w.Header().Set("X-Dump-Error", err.Error())
+		}
+	}
+}
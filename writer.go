@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ResourceWriter renders a resource's listed objects somewhere -- a
+// per-resource file for the CLI "dump" path, or directly onto an HTTP
+// response for the "serve" path -- describing what it did and how many
+// bytes it wrote, for the event stream.
+type ResourceWriter interface {
+	Write(resource Resource, list *unstructured.UnstructuredList) (description string, bytes int64, err error)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w     io.Writer
+	count int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count += int64(n)
+	return n, err
+}
+
+// fileResourceWriter writes each resource to its own file under outputDir,
+// named "<resource>.<format extension>". When a cache is set, a resource
+// whose objects all have the same resourceVersion as last run is left
+// untouched instead of being rewritten, and counted in stats.
+type fileResourceWriter struct {
+	outputDir   string
+	formatter   Formatter
+	kubeContext string
+	cache       *Cache
+
+	stats cacheStats
+}
+
+func newFileResourceWriter(outputDir string, formatter Formatter) *fileResourceWriter {
+	return &fileResourceWriter{outputDir: outputDir, formatter: formatter}
+}
+
+// cacheStats tracks how many resources were skipped because nothing in them
+// had changed since the last run.
+type cacheStats struct {
+	mu        sync.Mutex
+	unchanged int
+	total     int
+}
+
+func (s *cacheStats) recordUnchanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unchanged++
+	s.total++
+}
+
+func (s *cacheStats) recordChanged() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total++
+}
+
+func (s *cacheStats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%d/%d unchanged", s.unchanged, s.total)
+}
+
+func (f *fileResourceWriter) Write(resource Resource, list *unstructured.UnstructuredList) (string, int64, error) {
+	if err := os.MkdirAll(f.outputDir, os.ModePerm); err != nil {
+		return "", 0, fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	if f.cache != nil && f.resourceUnchanged(resource, list) {
+		f.stats.recordUnchanged()
+		return fmt.Sprintf("%s unchanged, skipped", resource.Name), 0, nil
+	}
+	if f.cache != nil {
+		f.stats.recordChanged()
+	}
+
+	filePath := filepath.Join(f.outputDir, fmt.Sprintf("%s.%s", resource.Name, f.formatter.Extension()))
+	file, err := os.Create(filePath)
+	if err != nil {
+		return "", 0, fmt.Errorf("error creating file %s: %w", filePath, err)
+	}
+	defer file.Close()
+
+	counting := &countingWriter{w: file}
+	if err := f.formatter.Write(counting, list); err != nil {
+		return "", 0, fmt.Errorf("error writing to file %s: %w", filePath, err)
+	}
+
+	return filePath, counting.count, nil
+}
+
+// resourceUnchanged reports whether every object in list matches the
+// resourceVersion or, failing that, the content hash recorded for it in the
+// cache, updating the cache entries as it goes so the next run has fresh
+// data regardless of the outcome. The hash fallback catches objects whose
+// resourceVersion bumped without the rendered content actually changing
+// (e.g. a status-only resync), so those aren't rewritten needlessly. A
+// resource type the cache has never seen before (namespace/name "" is a
+// sentinel no real object can have) is always reported changed, so the
+// first run -- and any resource whose object list happens to be empty --
+// still gets its file written instead of being skipped by default.
+func (f *fileResourceWriter) resourceUnchanged(resource Resource, list *unstructured.UnstructuredList) bool {
+	typeKey := cacheKey(f.kubeContext, resource, "", "")
+	_, seenBefore := f.cache.lookup(typeKey)
+	f.cache.update(typeKey, cacheEntry{})
+
+	unchanged := seenBefore
+
+	for i := range list.Items {
+		item := &list.Items[i]
+		key := cacheKey(f.kubeContext, resource, item.GetNamespace(), item.GetName())
+		hash := hashObject(item)
+
+		previous, found := f.cache.lookup(key)
+		if !found || (previous.ResourceVersion != item.GetResourceVersion() && previous.Hash != hash) {
+			unchanged = false
+		}
+
+		f.cache.update(key, cacheEntry{
+			ResourceVersion: item.GetResourceVersion(),
+			Hash:            hash,
+		})
+	}
+
+	return unchanged
+}
+
+// streamResourceWriter writes each resource's rendered chunk directly to w
+// as it completes, serialized with mu since resources are processed
+// concurrently. If w implements http.Flusher-like behavior via flush, it is
+// called after every chunk so a `serve` client sees a true chunked stream
+// rather than a buffered response.
+type streamResourceWriter struct {
+	w         io.Writer
+	formatter Formatter
+	flush     func()
+	mu        sync.Mutex
+}
+
+func newStreamResourceWriter(w io.Writer, formatter Formatter, flush func()) *streamResourceWriter {
+	return &streamResourceWriter{w: w, formatter: formatter, flush: flush}
+}
+
+func (s *streamResourceWriter) Write(resource Resource, list *unstructured.UnstructuredList) (string, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counting := &countingWriter{w: s.w}
+	if err := s.formatter.Write(counting, list); err != nil {
+		return "", 0, fmt.Errorf("error streaming resource %s: %w", resource.Name, err)
+	}
+
+	if s.flush != nil {
+		s.flush()
+	}
+
+	return fmt.Sprintf("streamed %s", resource.Name), counting.count, nil
+}